@@ -0,0 +1,85 @@
+// Package api defines data types to be shared between other packages
+// implementing the collectd network protocol.
+package api // import "collectd.org/api"
+
+import (
+	"context"
+	"time"
+)
+
+// Identifier uniquely identifies a value list.
+type Identifier struct {
+	Host                   string
+	Plugin, PluginInstance string
+	Type, TypeInstance     string
+}
+
+// String returns the string representation of the identifier, i.e.
+// "host/plugin-instance/type-instance".
+func (id Identifier) String() string {
+	str := id.Host + "/" + id.Plugin
+	if id.PluginInstance != "" {
+		str += "-" + id.PluginInstance
+	}
+	str += "/" + id.Type
+	if id.TypeInstance != "" {
+		str += "-" + id.TypeInstance
+	}
+	return str
+}
+
+// ValueList represents one (possibly multi-valued) data point at a
+// particular time.
+type ValueList struct {
+	Identifier
+	Time     time.Time
+	Interval time.Duration
+	Values   []Value
+	// DSNames holds the names of each of the values in Values. It is
+	// optional and, if non-nil, must have the same length as Values.
+	DSNames []string
+}
+
+// Value is a single value, such as a Gauge, a Derive or a Counter.
+type Value interface {
+	Type() ValueType
+}
+
+// ValueType identifies the kind of a Value.
+type ValueType int
+
+// Value types supported by collectd.
+const (
+	// TypeGauge represents an absolute value, such as a temperature.
+	TypeGauge ValueType = iota
+	// TypeDerive represents a monotonically increasing counter that may
+	// wrap around or be reset, such as bytes sent.
+	TypeDerive
+	// TypeCounter represents a monotonically increasing, wrapping 64bit
+	// counter.
+	TypeCounter
+)
+
+// Gauge represents an absolute value, such as a temperature.
+type Gauge float64
+
+// Type returns TypeGauge.
+func (Gauge) Type() ValueType { return TypeGauge }
+
+// Derive represents a monotonically increasing (or resetting) counter.
+type Derive int64
+
+// Type returns TypeDerive.
+func (Derive) Type() ValueType { return TypeDerive }
+
+// Counter represents a monotonically increasing, wrapping 64bit counter.
+type Counter uint64
+
+// Type returns TypeCounter.
+func (Counter) Type() ValueType { return TypeCounter }
+
+// Writer is implemented by types which can accept value lists, for example
+// to send them to collectd or to store them in a database.
+type Writer interface {
+	Write(ctx context.Context, vl *ValueList) error
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// Severity represents the severity of a Notification, see collectd's
+// NOTIF_* constants in plugin.h.
+type Severity uint64
+
+// Possible severities of a Notification.
+const (
+	Failure Severity = 1
+	Warning Severity = 2
+	Okay    Severity = 4
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Failure:
+		return "FAILURE"
+	case Warning:
+		return "WARNING"
+	case Okay:
+		return "OKAY"
+	default:
+		return fmt.Sprintf("Severity(%d)", uint64(s))
+	}
+}
+
+// Notification represents a status message such as the ones generated by
+// collectd's threshold or match-type plugins.
+type Notification struct {
+	Identifier
+	Time     time.Time
+	Severity Severity
+	Message  string
+}
@@ -0,0 +1,47 @@
+// Package cdtime implements collectd's internal time representation.
+//
+// Cdtime is represented as a 64bit fixed-point number, with the first 30
+// bits representing the fractional part of a second and the remaining 34
+// bits representing the number of seconds. This representation is used by
+// collectd's network protocol for the "High Resolution" time and interval
+// parts.
+package cdtime // import "collectd.org/cdtime"
+
+import "time"
+
+// Time represents a time in collectd's internal representation.
+type Time uint64
+
+// New returns a new Time representing the time t.
+func New(t time.Time) Time {
+	return fromUnix(t.Unix(), t.UnixNano()%1e9)
+}
+
+// NewDuration returns a new Time representing the duration d.
+func NewDuration(d time.Duration) Time {
+	return fromUnix(int64(d/time.Second), int64(d%time.Second))
+}
+
+// Time converts and returns the time as time.Time.
+func (t Time) Time() time.Time {
+	s, ns := t.unix()
+	return time.Unix(s, ns)
+}
+
+// Duration converts and returns the duration as time.Duration.
+func (t Time) Duration() time.Duration {
+	s, ns := t.unix()
+	return time.Duration(s)*time.Second + time.Duration(ns)*time.Nanosecond
+}
+
+func fromUnix(s, ns int64) Time {
+	hi := uint64(s) << 30
+	lo := (uint64(ns) << 30) / 1e9
+	return Time(hi | (lo & 0x3fffffff))
+}
+
+func (t Time) unix() (sec, nsec int64) {
+	sec = int64(t >> 30)
+	nsec = int64((uint64(t) & 0x3fffffff) * 1e9 / (1 << 30))
+	return sec, nsec
+}
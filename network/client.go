@@ -0,0 +1,113 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	"collectd.org/api"
+)
+
+// ClientOptions holds the configuration used by Dial.
+type ClientOptions struct {
+	// Network is passed to net.Dial, e.g. "udp", "udp4" or "udp6". It
+	// also selects which of DefaultIPv4Address/DefaultIPv6Address Dial
+	// falls back to when address is empty. It defaults to "udp", which
+	// resolves to the IPv4 default.
+	Network string
+
+	// BufferSize is the maximum packet size; see Writer.BufferSize.
+	BufferSize int
+
+	// TTL sets the multicast TTL (IP_MULTICAST_TTL) of outgoing IPv4
+	// packets, or the hop limit (IPV6_MULTICAST_HOPS) of outgoing IPv6
+	// packets. It is ignored for non-multicast addresses.
+	TTL int
+
+	// Username, AuthFile and SecurityLevel configure signing or
+	// encryption of outgoing packets; see Writer.
+	Username      string
+	AuthFile      AuthFile
+	SecurityLevel SecurityLevel
+}
+
+// Client sends value lists to a collectd server using collectd's binary
+// network protocol. It implements api.Writer.
+type Client struct {
+	conn net.Conn
+	w    *Writer
+}
+
+// Dial opens a UDP connection to address, which defaults to
+// "DefaultIPv4Address:DefaultService", or its IPv6 equivalent if
+// opts.Network is "udp6", when empty.
+func Dial(address string, opts ClientOptions) (*Client, error) {
+	network := opts.Network
+	if network == "" {
+		network = "udp"
+	}
+	if address == "" {
+		address = defaultAddress(network)
+	}
+
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.TTL > 0 {
+		if err := setMulticastTTL(conn, opts.TTL); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	w := NewWriter(conn)
+	if opts.BufferSize > 0 {
+		w.BufferSize = opts.BufferSize
+	}
+	w.Username = opts.Username
+	w.AuthFile = opts.AuthFile
+	w.SecurityLevel = opts.SecurityLevel
+
+	return &Client{conn: conn, w: w}, nil
+}
+
+// Write buffers vl, flushing a packet to the network whenever it would
+// otherwise exceed the client's buffer size. It implements api.Writer.
+func (c *Client) Write(ctx context.Context, vl *api.ValueList) error {
+	return c.w.Write(ctx, vl)
+}
+
+// Flush immediately sends any buffered data to the network.
+func (c *Client) Flush() error {
+	return c.w.Flush()
+}
+
+// Close flushes any buffered data and closes the underlying connection.
+func (c *Client) Close() error {
+	flushErr := c.Flush()
+	if err := c.conn.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
+
+func setMulticastTTL(conn net.Conn, ttl int) error {
+	udpAddr, ok := conn.RemoteAddr().(*net.UDPAddr)
+	if !ok {
+		return fmt.Errorf("network: %T is not a UDP connection", conn.RemoteAddr())
+	}
+	pc, ok := conn.(net.PacketConn)
+	if !ok {
+		return fmt.Errorf("network: %T is not a PacketConn", conn)
+	}
+
+	if udpAddr.IP.To4() != nil {
+		return ipv4.NewPacketConn(pc).SetMulticastTTL(ttl)
+	}
+	return ipv6.NewPacketConn(pc).SetMulticastHopLimit(ttl)
+}
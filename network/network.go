@@ -0,0 +1,57 @@
+package network
+
+import "net"
+
+// Part types, see commit 633c3966f7 of
+// https://github.com/collectd/collectd/commits/master/src/network.h
+const (
+	typeHost           = 0x0000
+	typeTime           = 0x0001
+	typePlugin         = 0x0002
+	typePluginInstance = 0x0003
+	typeType           = 0x0004
+	typeTypeInstance   = 0x0005
+	typeValues         = 0x0006
+	typeInterval       = 0x0007
+	typeTimeHR         = 0x0008
+	typeIntervalHR     = 0x0009
+
+	typeSignSHA256    = 0x0200
+	typeEncryptAES256 = 0x0210
+)
+
+// Data source types, see commit 633c3966f7 of
+// https://github.com/collectd/collectd/commits/master/src/plugin.h
+const (
+	dsTypeCounter = iota
+	dsTypeGauge
+	dsTypeDerive
+	dsTypeAbsolute
+)
+
+const (
+	// DefaultBufferSize is the default maximum packet size, matching the
+	// "MTU" option of collectd's network plugin.
+	DefaultBufferSize = 1452
+
+	// DefaultService is the default UDP service name (port) used by
+	// collectd's network plugin.
+	DefaultService = "25826"
+
+	// DefaultIPv4Address is collectd's default multicast group for IPv4.
+	DefaultIPv4Address = "239.192.74.66"
+
+	// DefaultIPv6Address is collectd's default multicast group for IPv6,
+	// as a bare literal suitable for net.JoinHostPort.
+	DefaultIPv6Address = "ff18::efc0:4a42"
+)
+
+// defaultAddress returns the "host:port" Dial and ListenAndWrite fall back
+// to when given an empty address, picking the IPv6 multicast default for
+// network "udp6" and the IPv4 one otherwise.
+func defaultAddress(network string) string {
+	if network == "udp6" {
+		return net.JoinHostPort(DefaultIPv6Address, DefaultService)
+	}
+	return net.JoinHostPort(DefaultIPv4Address, DefaultService)
+}
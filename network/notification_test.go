@@ -0,0 +1,47 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+)
+
+func TestWriterParseNotificationRoundtrip(t *testing.T) {
+	want := api.Notification{
+		Identifier: api.Identifier{Host: "example.com", Plugin: "cpu", Type: "cpu"},
+		Time:       time.Unix(1426588800, 0),
+		Severity:   api.Warning,
+		Message:    "load is high",
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteNotification(context.Background(), &want); err != nil {
+		t.Fatalf("WriteNotification: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	_, notifications, err := ParseOpts(buf.Bytes(), nil)
+	if err != nil {
+		t.Fatalf("ParseOpts: %v", err)
+	}
+	if len(notifications) != 1 {
+		t.Fatalf("ParseOpts returned %d notifications, want 1", len(notifications))
+	}
+
+	got := notifications[0]
+	if got.Identifier != want.Identifier {
+		t.Errorf("Identifier = %+v, want %+v", got.Identifier, want.Identifier)
+	}
+	if got.Severity != want.Severity {
+		t.Errorf("Severity = %v, want %v", got.Severity, want.Severity)
+	}
+	if got.Message != want.Message {
+		t.Errorf("Message = %q, want %q", got.Message, want.Message)
+	}
+}
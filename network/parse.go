@@ -2,10 +2,13 @@ package network
 
 import (
 	"bytes"
+	"crypto/aes"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"strings"
 	"time"
 
@@ -86,19 +89,70 @@ func (p Packet) FormatName() string {
 	return metricName
 }
 
-// Parse parses the binary network format and returns all
+// ParseOptions controls the behavior of ParseOpts.
+type ParseOptions struct {
+	// AuthFile is consulted to verify signed parts and decrypt encrypted
+	// parts. It is required if SecurityLevel is Sign or Encrypt, and
+	// also used to verify/decrypt parts found in packets that don't
+	// require it.
+	AuthFile AuthFile
+
+	// SecurityLevel is the minimum security level required of incoming
+	// packets; packets that don't meet it are rejected with an error.
+	SecurityLevel SecurityLevel
+}
+
+// Parse parses the binary network format and returns all value lists
+// contained in it. It is a thin wrapper around ParseOpts with the zero
+// ParseOptions, i.e. without an AuthFile, so signed or encrypted packets
+// can be neither verified nor decrypted and are rejected with an error.
 func Parse(b []byte) ([]api.ValueList, error) {
-	var valueLists []api.ValueList
+	valueLists, _, err := ParseOpts(b, nil)
+	return valueLists, err
+}
 
-	var state api.ValueList
-	buf := bytes.NewBuffer(b)
+// ParseOpts parses the binary network format like Parse, additionally
+// verifying signed parts and decrypting encrypted parts (part types
+// SignSHA256 and EncryptAES256) as configured by opts, and returning any
+// notifications found alongside the value lists. opts may be nil, which is
+// equivalent to the zero ParseOptions.
+func ParseOpts(b []byte, opts *ParseOptions) ([]api.ValueList, []api.Notification, error) {
+	if opts == nil {
+		opts = &ParseOptions{}
+	}
+
+	p := parser{opts: opts}
+	valueLists, notifications, err := p.parse(bytes.NewBuffer(b))
+	if err != nil {
+		return valueLists, notifications, err
+	}
+	if p.level < opts.SecurityLevel {
+		return valueLists, notifications, fmt.Errorf("network: packet security level %v is below the required %v", p.level, opts.SecurityLevel)
+	}
+	return valueLists, notifications, nil
+}
+
+// parser holds the state accumulated while parsing a single packet: the
+// most recently seen identifier/time/interval fields and pending severity
+// (collectd's state-diff encoding), plus the highest security level
+// observed so far, including in nested, decrypted parts.
+type parser struct {
+	opts     *ParseOptions
+	state    api.ValueList
+	severity api.Severity
+	level    SecurityLevel
+}
+
+func (p *parser) parse(buf *bytes.Buffer) ([]api.ValueList, []api.Notification, error) {
+	var valueLists []api.ValueList
+	var notifications []api.Notification
 
 	for buf.Len() > 0 {
 		partType := binary.BigEndian.Uint16(buf.Next(2))
 		partLength := int(binary.BigEndian.Uint16(buf.Next(2)))
 
 		if partLength < 5 || partLength-4 > buf.Len() {
-			return valueLists, fmt.Errorf("invalid length %d", partLength)
+			return valueLists, notifications, fmt.Errorf("invalid length %d", partLength)
 		}
 
 		// First 4 bytes were already read
@@ -106,57 +160,111 @@ func Parse(b []byte) ([]api.ValueList, error) {
 
 		payload := buf.Next(partLength)
 		if len(payload) != partLength {
-			return valueLists, fmt.Errorf("invalid length: want %d, got %d", partLength, len(payload))
+			return valueLists, notifications, fmt.Errorf("invalid length: want %d, got %d", partLength, len(payload))
 		}
 
 		switch partType {
 		case typeHost, typePlugin, typePluginInstance, typeType, typeTypeInstance:
 			str, err := parseString(payload)
 			if err != nil {
-				return valueLists, err
+				return valueLists, notifications, err
 			}
 			switch partType {
 			case typeHost:
-				state.Identifier.Host = str
+				p.state.Identifier.Host = str
 			case typePlugin:
-				state.Identifier.Plugin = str
+				p.state.Identifier.Plugin = str
 			case typePluginInstance:
-				state.Identifier.PluginInstance = str
+				p.state.Identifier.PluginInstance = str
 			case typeType:
-				state.Identifier.Type = str
+				p.state.Identifier.Type = str
 			case typeTypeInstance:
-				state.Identifier.TypeInstance = str
+				p.state.Identifier.TypeInstance = str
 			}
 		case typeInterval, typeIntervalHR, typeTime, typeTimeHR:
 			i, err := parseInt(payload)
 			if err != nil {
-				return valueLists, err
+				return valueLists, notifications, err
 			}
 			switch partType {
 			case typeInterval:
-				state.Interval = time.Duration(i) * time.Second
+				p.state.Interval = time.Duration(i) * time.Second
 			case typeIntervalHR:
-				state.Interval = cdtime.Time(i).Duration()
+				p.state.Interval = cdtime.Time(i).Duration()
 			case typeTime:
-				state.Time = time.Unix(int64(i), 0)
+				p.state.Time = time.Unix(int64(i), 0)
 			case typeTimeHR:
-				state.Time = cdtime.Time(i).Time()
+				p.state.Time = cdtime.Time(i).Time()
 			}
 		case typeValues:
-			vl := state
+			vl := p.state
 			var err error
 			if vl.Values, err = parseValues(payload); err != nil {
-				return valueLists, err
+				return valueLists, notifications, err
 			}
 
 			valueLists = append(valueLists, vl)
 
+		case ParseSeverity:
+			i, err := parseInt(payload)
+			if err != nil {
+				return valueLists, notifications, err
+			}
+			p.severity = api.Severity(i)
+
+		case ParseMessage:
+			str, err := parseString(payload)
+			if err != nil {
+				return valueLists, notifications, err
+			}
+			notifications = append(notifications, api.Notification{
+				Identifier: p.state.Identifier,
+				Time:       p.state.Time,
+				Severity:   p.severity,
+				Message:    str,
+			})
+
+		case typeSignSHA256:
+			if len(payload) < sha256.Size {
+				return valueLists, notifications, fmt.Errorf("network: sign part too short (%d bytes)", len(payload))
+			}
+			mac, username := payload[:sha256.Size], string(payload[sha256.Size:])
+			if err := verifySignature(p.opts.AuthFile, username, mac, buf.Bytes()); err != nil {
+				return valueLists, notifications, err
+			}
+			if p.level < Sign {
+				p.level = Sign
+			}
+
+		case typeEncryptAES256:
+			pbuf := bytes.NewBuffer(payload)
+			var usernameLength uint16
+			if err := binary.Read(pbuf, binary.BigEndian, &usernameLength); err != nil {
+				return valueLists, notifications, err
+			}
+			username := string(pbuf.Next(int(usernameLength)))
+			iv := pbuf.Next(aes.BlockSize)
+			plaintext, err := decrypt(p.opts.AuthFile, username, iv, pbuf.Bytes())
+			if err != nil {
+				return valueLists, notifications, err
+			}
+			if p.level < Encrypt {
+				p.level = Encrypt
+			}
+
+			innerValueLists, innerNotifications, err := p.parse(bytes.NewBuffer(plaintext))
+			if err != nil {
+				return valueLists, notifications, err
+			}
+			valueLists = append(valueLists, innerValueLists...)
+			notifications = append(notifications, innerNotifications...)
+
 		default:
 			log.Printf("ignoring field of type %#x", partType)
 		}
 	}
 
-	return valueLists, nil
+	return valueLists, notifications, nil
 }
 
 func parseValues(b []byte) ([]api.Value, error) {
@@ -187,13 +295,20 @@ func parseValues(b []byte) ([]api.Value, error) {
 			}
 			values[i] = api.Gauge(v)
 
-		case dsTypeDerive, dsTypeCounter:
+		case dsTypeDerive:
 			var v int64
 			if err := binary.Read(buffer, binary.BigEndian, &v); err != nil {
 				return nil, err
 			}
 			values[i] = api.Derive(v)
 
+		case dsTypeCounter:
+			var v uint64
+			if err := binary.Read(buffer, binary.BigEndian, &v); err != nil {
+				return nil, err
+			}
+			values[i] = api.Counter(v)
+
 		case dsTypeAbsolute:
 			return nil, ErrorUnsupported
 
@@ -0,0 +1,163 @@
+package network
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// SecurityLevel determines whether collectd network packets must be signed
+// or encrypted, see collectd's "network" plugin "SecurityLevel" option.
+type SecurityLevel int
+
+const (
+	// None requires neither signing nor encryption.
+	None SecurityLevel = iota
+	// Sign requires packets to be at least signed; encrypted packets are
+	// accepted too.
+	Sign
+	// Encrypt requires packets to be encrypted.
+	Encrypt
+)
+
+func (l SecurityLevel) String() string {
+	switch l {
+	case None:
+		return "None"
+	case Sign:
+		return "Sign"
+	case Encrypt:
+		return "Encrypt"
+	default:
+		return fmt.Sprintf("SecurityLevel(%d)", int(l))
+	}
+}
+
+// AuthFile maps user names to passwords, like the file named by collectd's
+// "AuthFile" network plugin option. It is a plain map: this package does
+// not read files in that "user: password" per-line format.
+type AuthFile map[string]string
+
+// password looks up the password for user.
+func (a AuthFile) password(user string) (string, error) {
+	password, ok := a[user]
+	if !ok {
+		return "", fmt.Errorf("network: unknown user %q", user)
+	}
+	return password, nil
+}
+
+// verifySignature checks the SHA-256 HMAC mac, computed by the sender over
+// username and remainder, using the password associated with username in
+// auth.
+func verifySignature(auth AuthFile, username string, mac, remainder []byte) error {
+	password, err := auth.password(username)
+	if err != nil {
+		return err
+	}
+
+	h := hmac.New(sha256.New, []byte(password))
+	h.Write([]byte(username))
+	h.Write(remainder)
+
+	if !hmac.Equal(mac, h.Sum(nil)) {
+		return fmt.Errorf("network: invalid signature for user %q", username)
+	}
+	return nil
+}
+
+// signPart signs remainder with the password associated with username and
+// returns the serialized SignSHA256 part followed by remainder.
+func signPart(auth AuthFile, username string, remainder []byte) ([]byte, error) {
+	password, err := auth.password(username)
+	if err != nil {
+		return nil, err
+	}
+
+	h := hmac.New(sha256.New, []byte(password))
+	h.Write([]byte(username))
+	h.Write(remainder)
+	mac := h.Sum(nil)
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(typeSignSHA256))
+	binary.Write(&buf, binary.BigEndian, uint16(4+len(mac)+len(username)))
+	buf.Write(mac)
+	buf.WriteString(username)
+	buf.Write(remainder)
+	return buf.Bytes(), nil
+}
+
+// decrypt decrypts an AES-256-OFB encrypted part, whose decrypted data must
+// be the SHA-1 hash of the content followed by the content itself, checks
+// the hash and returns the content.
+func decrypt(auth AuthFile, username string, iv, ciphertext []byte) ([]byte, error) {
+	password, err := auth.password(username)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("network: invalid IV length %d", len(iv))
+	}
+
+	key := sha256.Sum256([]byte(password))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewOFB(block, iv).XORKeyStream(plaintext, ciphertext)
+
+	if len(plaintext) < sha1.Size {
+		return nil, fmt.Errorf("network: encrypted payload too short")
+	}
+	wantHash, content := plaintext[:sha1.Size], plaintext[sha1.Size:]
+	gotHash := sha1.Sum(content)
+	if !bytes.Equal(wantHash, gotHash[:]) {
+		return nil, fmt.Errorf("network: invalid hash for user %q, packet has been tampered with", username)
+	}
+
+	return content, nil
+}
+
+// encryptPart encrypts content with the password associated with username
+// and returns the serialized EncryptAES256 part.
+func encryptPart(auth AuthFile, username string, content []byte) ([]byte, error) {
+	password, err := auth.password(username)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+
+	hash := sha1.Sum(content)
+	plaintext := append(append([]byte{}, hash[:]...), content...)
+
+	key := sha256.Sum256([]byte(password))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewOFB(block, iv).XORKeyStream(ciphertext, plaintext)
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(typeEncryptAES256))
+	binary.Write(&buf, binary.BigEndian, uint16(4+2+len(username)+len(iv)+len(ciphertext)))
+	binary.Write(&buf, binary.BigEndian, uint16(len(username)))
+	buf.WriteString(username)
+	buf.Write(iv)
+	buf.Write(ciphertext)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,95 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+)
+
+var testAuthFile = AuthFile{"user": "secret"}
+
+func TestWriterParseSigned(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Username = "user"
+	w.AuthFile = testAuthFile
+	w.SecurityLevel = Sign
+
+	vl := api.ValueList{
+		Identifier: api.Identifier{Host: "example.com", Plugin: "cpu", Type: "cpu"},
+		Time:       time.Unix(1426588800, 0),
+		Interval:   10 * time.Second,
+		Values:     []api.Value{api.Derive(1)},
+	}
+	if err := w.Write(context.Background(), &vl); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	got, _, err := ParseOpts(buf.Bytes(), &ParseOptions{AuthFile: testAuthFile, SecurityLevel: Sign})
+	if err != nil {
+		t.Fatalf("ParseOpts: %v", err)
+	}
+	if len(got) != 1 || got[0].Identifier != vl.Identifier {
+		t.Fatalf("ParseOpts = %+v, want one value list matching %+v", got, vl)
+	}
+
+	if _, _, err := ParseOpts(buf.Bytes(), &ParseOptions{AuthFile: AuthFile{"user": "wrong"}, SecurityLevel: Sign}); err == nil {
+		t.Fatal("ParseOpts succeeded with the wrong password, want error")
+	}
+}
+
+func TestWriterParseEncrypted(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Username = "user"
+	w.AuthFile = testAuthFile
+	w.SecurityLevel = Encrypt
+
+	vl := api.ValueList{
+		Identifier: api.Identifier{Host: "example.com", Plugin: "memory", Type: "memory"},
+		Time:       time.Unix(1426588800, 0),
+		Interval:   10 * time.Second,
+		Values:     []api.Value{api.Gauge(1234.5)},
+	}
+	if err := w.Write(context.Background(), &vl); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	got, _, err := ParseOpts(buf.Bytes(), &ParseOptions{AuthFile: testAuthFile, SecurityLevel: Encrypt})
+	if err != nil {
+		t.Fatalf("ParseOpts: %v", err)
+	}
+	if len(got) != 1 || got[0].Identifier != vl.Identifier {
+		t.Fatalf("ParseOpts = %+v, want one value list matching %+v", got, vl)
+	}
+}
+
+func TestParseRejectsBelowMinimumSecurityLevel(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	vl := api.ValueList{
+		Identifier: api.Identifier{Host: "example.com", Plugin: "cpu", Type: "cpu"},
+		Time:       time.Unix(1426588800, 0),
+		Interval:   10 * time.Second,
+		Values:     []api.Value{api.Derive(1)},
+	}
+	if err := w.Write(context.Background(), &vl); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	if _, _, err := ParseOpts(buf.Bytes(), &ParseOptions{AuthFile: testAuthFile, SecurityLevel: Sign}); err == nil {
+		t.Fatal("ParseOpts succeeded on an unsigned packet with SecurityLevel: Sign, want error")
+	}
+}
@@ -0,0 +1,102 @@
+package network
+
+import (
+	"context"
+	"log"
+	"net"
+
+	"collectd.org/api"
+)
+
+// ServerOptions holds the configuration used by ListenAndWrite.
+type ServerOptions struct {
+	// Network is passed to net.ResolveUDPAddr, e.g. "udp", "udp4" or
+	// "udp6". It also selects which of
+	// DefaultIPv4Address/DefaultIPv6Address ListenAndWrite falls back to
+	// when address is empty. It defaults to "udp", which resolves to the
+	// IPv4 default.
+	Network string
+
+	// BufferSize is the size of the buffer used to read incoming
+	// datagrams. It must be at least as large as the largest packet sent
+	// by a client; it defaults to DefaultBufferSize.
+	BufferSize int
+
+	// ParseOpts is passed to ParseOpts for every received packet,
+	// allowing the server to require signed or encrypted packets.
+	ParseOpts ParseOptions
+}
+
+// Server receives collectd network packets on a UDP socket, parses them and
+// dispatches the resulting value lists to a Writer.
+type Server struct {
+	conn net.PacketConn
+	w    api.Writer
+	opts ServerOptions
+}
+
+// ListenAndWrite listens on address, a UDP unicast or multicast address
+// (defaulting to "DefaultIPv4Address:DefaultService", or its IPv6
+// equivalent if opts.Network is "udp6", when empty), and calls w.Write for
+// every api.ValueList parsed out of incoming packets. It blocks until ctx
+// is cancelled or a read error occurs.
+func ListenAndWrite(ctx context.Context, address string, w api.Writer, opts ServerOptions) error {
+	network := opts.Network
+	if network == "" {
+		network = "udp"
+	}
+	if address == "" {
+		address = defaultAddress(network)
+	}
+
+	udpAddr, err := net.ResolveUDPAddr(network, address)
+	if err != nil {
+		return err
+	}
+
+	var conn *net.UDPConn
+	if udpAddr.IP != nil && udpAddr.IP.IsMulticast() {
+		conn, err = net.ListenMulticastUDP(network, nil, udpAddr)
+	} else {
+		conn, err = net.ListenUDP(network, udpAddr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	srv := &Server{conn: conn, w: w, opts: opts}
+	return srv.serve()
+}
+
+func (s *Server) serve() error {
+	size := s.opts.BufferSize
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	buf := make([]byte, size)
+
+	for {
+		n, _, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		valueLists, _, err := ParseOpts(buf[:n], &s.opts.ParseOpts)
+		if err != nil {
+			log.Printf("network: Parse: %v", err)
+			continue
+		}
+
+		for i := range valueLists {
+			if err := s.w.Write(context.Background(), &valueLists[i]); err != nil {
+				log.Printf("network: Write: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package network
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"collectd.org/api"
+)
+
+// RangePolicy determines what happens to gauge values that fall outside
+// the Min/Max bounds declared for their data source in a Types database.
+type RangePolicy int
+
+const (
+	// RangeNone disables range checking; this is the default.
+	RangeNone RangePolicy = iota
+	// RangeError causes Parser.Parse to return an error when a gauge
+	// value is out of range.
+	RangeError
+	// RangeDrop replaces out-of-range gauge values with math.NaN,
+	// mirroring the way collectd's own daemon discards the value while
+	// keeping the rest of the value list.
+	RangeDrop
+)
+
+// Parser parses collectd network packets like ParseOpts, additionally
+// consulting a Types database to name and validate the values of each
+// returned value list.
+type Parser struct {
+	// Opts is passed to ParseOpts, see there.
+	Opts ParseOptions
+
+	// Types, if non-nil, is consulted to fill in DSNames and to
+	// validate each value list's arity (and, depending on RangeCheck,
+	// gauge bounds) against the data set matching its Type.
+	Types Types
+
+	// RangeCheck determines what happens to out-of-range gauge values;
+	// it has no effect if Types is nil.
+	RangeCheck RangePolicy
+}
+
+// Parse parses b like ParseOpts, additionally naming and validating values
+// using p.Types.
+func (p *Parser) Parse(b []byte) ([]api.ValueList, []api.Notification, error) {
+	valueLists, notifications, err := ParseOpts(b, &p.Opts)
+	if err != nil {
+		return valueLists, notifications, err
+	}
+
+	if p.Types != nil {
+		for i := range valueLists {
+			if err := p.Types.annotate(&valueLists[i], p.RangeCheck); err != nil {
+				return valueLists, notifications, err
+			}
+		}
+	}
+
+	return valueLists, notifications, nil
+}
+
+// annotate fills in vl.DSNames and validates the number of values (and,
+// depending on policy, their range) using the data set registered under
+// vl.Type.
+func (types Types) annotate(vl *api.ValueList, policy RangePolicy) error {
+	dataSet, ok := types[vl.Type]
+	if !ok {
+		return fmt.Errorf("network: type %q not found in types.db", vl.Type)
+	}
+	if len(dataSet) != len(vl.Values) {
+		return fmt.Errorf("network: type %q has %d data sources, but value list has %d values", vl.Type, len(dataSet), len(vl.Values))
+	}
+
+	dsNames := make([]string, len(vl.Values))
+	for i, ds := range dataSet {
+		dsNames[i] = ds.Name
+
+		if policy == RangeNone {
+			continue
+		}
+		gauge, ok := vl.Values[i].(api.Gauge)
+		if !ok {
+			continue
+		}
+		min, hasMin := ds.min()
+		max, hasMax := ds.max()
+		if (hasMin && float64(gauge) < min) || (hasMax && float64(gauge) > max) {
+			if policy == RangeDrop {
+				vl.Values[i] = api.Gauge(math.NaN())
+				continue
+			}
+			return fmt.Errorf("network: value %v for %q is out of the [%s, %s] range defined in types.db", gauge, dsNames[i], ds.Min, ds.Max)
+		}
+	}
+	vl.DSNames = dsNames
+
+	return nil
+}
+
+// min parses the data source's minimum, as found in a types.db(5) file.
+// ok is false if the bound is unset ("U").
+func (t *Type) min() (value float64, ok bool) {
+	return parseBound(t.Min)
+}
+
+// max parses the data source's maximum, as found in a types.db(5) file.
+// ok is false if the bound is unset ("U").
+func (t *Type) max() (value float64, ok bool) {
+	return parseBound(t.Max)
+}
+
+func parseBound(s string) (float64, bool) {
+	if s == "" || s == "U" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// MergeTypesDB merges one or more Types, as returned by TypesDB or
+// TypesDBFile, into a single Types, matching collectd's own ability to
+// layer several "TypesDB" files on top of each other. Data sets defined in
+// later arguments take precedence over earlier ones.
+func MergeTypesDB(dbs ...Types) Types {
+	merged := make(Types)
+	for _, db := range dbs {
+		for name, dataSet := range db {
+			merged[name] = dataSet
+		}
+	}
+	return merged
+}
@@ -0,0 +1,105 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+)
+
+const testTypesDB = "load\tshortterm:GAUGE:0:100, midterm:GAUGE:0:100, longterm:GAUGE:0:100\n"
+
+func packet(t *testing.T, vl api.ValueList) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.Write(context.Background(), &vl); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestParserNamesValuesFromTypesDB(t *testing.T) {
+	types, err := TypesDB([]byte(testTypesDB))
+	if err != nil {
+		t.Fatalf("TypesDB: %v", err)
+	}
+
+	b := packet(t, api.ValueList{
+		Identifier: api.Identifier{Host: "example.com", Plugin: "load", Type: "load"},
+		Time:       time.Unix(1426588800, 0),
+		Interval:   10 * time.Second,
+		Values:     []api.Value{api.Gauge(1), api.Gauge(2), api.Gauge(3)},
+	})
+
+	p := Parser{Types: types}
+	got, _, err := p.Parse(b)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []string{"shortterm", "midterm", "longterm"}
+	if len(got) != 1 {
+		t.Fatalf("Parse returned %d value lists, want 1", len(got))
+	}
+	for i, name := range want {
+		if got[0].DSNames[i] != name {
+			t.Errorf("DSNames[%d] = %q, want %q", i, got[0].DSNames[i], name)
+		}
+	}
+}
+
+func TestParserRejectsArityMismatch(t *testing.T) {
+	types, err := TypesDB([]byte(testTypesDB))
+	if err != nil {
+		t.Fatalf("TypesDB: %v", err)
+	}
+
+	b := packet(t, api.ValueList{
+		Identifier: api.Identifier{Host: "example.com", Plugin: "load", Type: "load"},
+		Time:       time.Unix(1426588800, 0),
+		Interval:   10 * time.Second,
+		Values:     []api.Value{api.Gauge(1)},
+	})
+
+	p := Parser{Types: types}
+	if _, _, err := p.Parse(b); err == nil {
+		t.Fatal("Parse succeeded despite an arity mismatch, want error")
+	}
+}
+
+func TestParserRangeCheck(t *testing.T) {
+	types, err := TypesDB([]byte(testTypesDB))
+	if err != nil {
+		t.Fatalf("TypesDB: %v", err)
+	}
+
+	b := packet(t, api.ValueList{
+		Identifier: api.Identifier{Host: "example.com", Plugin: "load", Type: "load"},
+		Time:       time.Unix(1426588800, 0),
+		Interval:   10 * time.Second,
+		Values:     []api.Value{api.Gauge(1000), api.Gauge(2), api.Gauge(3)},
+	})
+
+	errP := Parser{Types: types, RangeCheck: RangeError}
+	if _, _, err := errP.Parse(b); err == nil {
+		t.Fatal("Parse succeeded despite an out-of-range value with RangeError, want error")
+	}
+
+	dropP := Parser{Types: types, RangeCheck: RangeDrop}
+	got, _, err := dropP.Parse(b)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if g, ok := got[0].Values[0].(api.Gauge); !ok || !isNaN(float64(g)) {
+		t.Errorf("Values[0] = %v, want NaN", got[0].Values[0])
+	}
+}
+
+func isNaN(f float64) bool {
+	return f != f
+}
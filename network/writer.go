@@ -0,0 +1,244 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"collectd.org/api"
+	"collectd.org/cdtime"
+)
+
+// Writer serializes ValueLists into collectd's binary network format and
+// writes completed packets to an underlying io.Writer. It implements
+// api.Writer.
+//
+// Like collectd's own "network" plugin, Writer remembers the identifier and
+// interval fields of the last value list it wrote and omits parts that
+// haven't changed, so a Writer must not be reused across unrelated streams
+// of value lists.
+type Writer struct {
+	w io.Writer
+
+	// BufferSize is the maximum size of a packet flushed to the
+	// underlying writer, including the part added when signing or
+	// encrypting. It defaults to DefaultBufferSize, collectd's default
+	// network "MTU", if zero or negative.
+	BufferSize int
+
+	// Username, AuthFile and SecurityLevel, if SecurityLevel is not
+	// None, cause every flushed packet to be signed (Sign) or encrypted
+	// (Encrypt) using the password associated with Username in AuthFile.
+	Username      string
+	AuthFile      AuthFile
+	SecurityLevel SecurityLevel
+
+	buf  bytes.Buffer
+	last api.ValueList
+}
+
+// NewWriter returns a new Writer that flushes completed packets to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:          w,
+		BufferSize: DefaultBufferSize,
+	}
+}
+
+// Write appends vl to the current packet, flushing the buffered packet to
+// the underlying io.Writer first if vl would otherwise make it exceed
+// BufferSize. It implements api.Writer.
+func (w *Writer) Write(_ context.Context, vl *api.ValueList) error {
+	if len(vl.Values) == 0 {
+		return fmt.Errorf("network: value list for %q has no values", vl.Identifier)
+	}
+
+	part := w.encodeValueList(vl)
+	if err := writeValues(&part, vl.Values); err != nil {
+		return err
+	}
+	if max := w.bufferSize(); w.buf.Len() > 0 && w.buf.Len()+part.Len() > max {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		// Flush reset the state-diff cache, so fields that were
+		// omitted above because they matched it now need to be
+		// resent at the start of the new packet.
+		part = w.encodeValueList(vl)
+		writeValues(&part, vl.Values) // the values were accepted above
+	}
+
+	if _, err := w.buf.Write(part.Bytes()); err != nil {
+		return err
+	}
+
+	w.last = *vl
+	return nil
+}
+
+// encodeValueList encodes the identifier, interval and time parts of vl
+// that differ from w.last, without writing the values part and without
+// updating w.last.
+func (w *Writer) encodeValueList(vl *api.ValueList) bytes.Buffer {
+	part := w.encodeIdentifier(vl.Identifier)
+	if vl.Interval != w.last.Interval {
+		writeNumeric(&part, typeIntervalHR, uint64(cdtime.NewDuration(vl.Interval)))
+	}
+	writeNumeric(&part, typeTimeHR, uint64(cdtime.New(vl.Time)))
+	return part
+}
+
+// encodeIdentifier encodes the parts of id that differ from w.last,
+// without updating w.last.
+func (w *Writer) encodeIdentifier(id api.Identifier) bytes.Buffer {
+	var part bytes.Buffer
+	if id.Host != w.last.Host {
+		writeString(&part, typeHost, id.Host)
+	}
+	if id.Plugin != w.last.Plugin {
+		writeString(&part, typePlugin, id.Plugin)
+	}
+	if id.PluginInstance != w.last.PluginInstance {
+		writeString(&part, typePluginInstance, id.PluginInstance)
+	}
+	if id.Type != w.last.Type {
+		writeString(&part, typeType, id.Type)
+	}
+	if id.TypeInstance != w.last.TypeInstance {
+		writeString(&part, typeTypeInstance, id.TypeInstance)
+	}
+	return part
+}
+
+// WriteNotification appends n to the current packet, flushing the buffered
+// packet first if necessary, exactly like Write does for value lists.
+func (w *Writer) WriteNotification(_ context.Context, n *api.Notification) error {
+	encode := func() bytes.Buffer {
+		part := w.encodeIdentifier(n.Identifier)
+		writeNumeric(&part, typeTimeHR, uint64(cdtime.New(n.Time)))
+		writeNumeric(&part, ParseSeverity, uint64(n.Severity))
+		writeString(&part, ParseMessage, n.Message)
+		return part
+	}
+
+	part := encode()
+	if max := w.bufferSize(); w.buf.Len() > 0 && w.buf.Len()+part.Len() > max {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		// Flush reset the state-diff cache; see the comment in Write.
+		part = encode()
+	}
+	if _, err := w.buf.Write(part.Bytes()); err != nil {
+		return err
+	}
+
+	w.last.Identifier = n.Identifier
+	return nil
+}
+
+// bufferSize returns the number of bytes that may be buffered, leaving room
+// for the part Flush adds when signing or encrypting.
+func (w *Writer) bufferSize() int {
+	size := DefaultBufferSize
+	if w.BufferSize > 0 {
+		size = w.BufferSize
+	}
+	switch w.SecurityLevel {
+	case Sign:
+		size -= 4 + sha256.Size + len(w.Username)
+	case Encrypt:
+		size -= 4 + 2 + len(w.Username) + aes.BlockSize + sha1.Size
+	}
+	return size
+}
+
+// Flush writes any buffered data to the underlying io.Writer as a single
+// packet, signing or encrypting it first if SecurityLevel is set.
+func (w *Writer) Flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+
+	packet := w.buf.Bytes()
+	switch w.SecurityLevel {
+	case Encrypt:
+		encrypted, err := encryptPart(w.AuthFile, w.Username, packet)
+		if err != nil {
+			return err
+		}
+		packet = encrypted
+	case Sign:
+		signed, err := signPart(w.AuthFile, w.Username, packet)
+		if err != nil {
+			return err
+		}
+		packet = signed
+	}
+
+	_, err := w.w.Write(packet)
+	w.buf.Reset()
+
+	// Each flushed packet is a standalone datagram as far as a receiver
+	// is concerned; Parse/ParseOpts resets its own identifier state for
+	// every call, so our state-diff cache must be reset here too, or
+	// the first value list of the next packet would wrongly omit
+	// identifier parts that happen to match the previous packet's.
+	w.last = api.ValueList{}
+
+	return err
+}
+
+func writeString(buf *bytes.Buffer, typ uint16, s string) {
+	binary.Write(buf, binary.BigEndian, typ)
+	binary.Write(buf, binary.BigEndian, uint16(4+len(s)+1))
+	buf.WriteString(s)
+	buf.WriteByte(0)
+}
+
+func writeNumeric(buf *bytes.Buffer, typ uint16, v uint64) {
+	binary.Write(buf, binary.BigEndian, typ)
+	binary.Write(buf, binary.BigEndian, uint16(4+8))
+	binary.Write(buf, binary.BigEndian, v)
+}
+
+func writeValues(buf *bytes.Buffer, values []api.Value) error {
+	var vbuf bytes.Buffer
+	binary.Write(&vbuf, binary.BigEndian, uint16(len(values)))
+
+	types := make([]byte, len(values))
+	for i, v := range values {
+		switch v.(type) {
+		case api.Gauge:
+			types[i] = dsTypeGauge
+		case api.Derive:
+			types[i] = dsTypeDerive
+		case api.Counter:
+			types[i] = dsTypeCounter
+		default:
+			return fmt.Errorf("network: unsupported value type %T", v)
+		}
+	}
+	vbuf.Write(types)
+
+	for _, v := range values {
+		switch value := v.(type) {
+		case api.Gauge:
+			binary.Write(&vbuf, binary.LittleEndian, float64(value))
+		case api.Derive:
+			binary.Write(&vbuf, binary.BigEndian, int64(value))
+		case api.Counter:
+			binary.Write(&vbuf, binary.BigEndian, uint64(value))
+		}
+	}
+
+	binary.Write(buf, binary.BigEndian, uint16(typeValues))
+	binary.Write(buf, binary.BigEndian, uint16(4+vbuf.Len()))
+	buf.Write(vbuf.Bytes())
+	return nil
+}
@@ -0,0 +1,169 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+)
+
+func TestWriterParseRoundtrip(t *testing.T) {
+	want := []api.ValueList{
+		{
+			Identifier: api.Identifier{
+				Host:   "example.com",
+				Plugin: "cpu",
+				Type:   "cpu",
+			},
+			Time:     time.Unix(1426588800, 0),
+			Interval: 10 * time.Second,
+			Values:   []api.Value{api.Derive(42)},
+		},
+		{
+			Identifier: api.Identifier{
+				Host:   "example.com",
+				Plugin: "memory",
+				Type:   "memory",
+			},
+			Time:     time.Unix(1426588810, 0),
+			Interval: 10 * time.Second,
+			Values:   []api.Value{api.Gauge(1234.5)},
+		},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i := range want {
+		if err := w.Write(context.Background(), &want[i]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	got, err := Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Parse returned %d value lists, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Identifier != want[i].Identifier {
+			t.Errorf("value list %d: Identifier = %+v, want %+v", i, got[i].Identifier, want[i].Identifier)
+		}
+		if got[i].Interval != want[i].Interval {
+			t.Errorf("value list %d: Interval = %v, want %v", i, got[i].Interval, want[i].Interval)
+		}
+		if len(got[i].Values) != 1 || got[i].Values[0] != want[i].Values[0] {
+			t.Errorf("value list %d: Values = %v, want %v", i, got[i].Values, want[i].Values)
+		}
+	}
+}
+
+func TestWriterParseCounterRoundtrip(t *testing.T) {
+	// A value above math.MaxInt64 must survive the roundtrip as an
+	// api.Counter; folding it into a signed api.Derive would turn it
+	// negative.
+	want := api.ValueList{
+		Identifier: api.Identifier{Host: "example.com", Plugin: "if", Type: "if_octets"},
+		Time:       time.Unix(1426588800, 0),
+		Interval:   10 * time.Second,
+		Values:     []api.Value{api.Counter(1 << 63)},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.Write(context.Background(), &want); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	got, err := Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(got) != 1 || len(got[0].Values) != 1 {
+		t.Fatalf("Parse returned %+v, want one value list with one value", got)
+	}
+	if got[0].Values[0] != want.Values[0] {
+		t.Errorf("Values[0] = %#v, want %#v", got[0].Values[0], want.Values[0])
+	}
+}
+
+func TestWriterFlushesFullPacket(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.BufferSize = 64
+
+	vl := api.ValueList{
+		Identifier: api.Identifier{Host: "example.com", Plugin: "cpu", Type: "cpu"},
+		Time:       time.Unix(1426588800, 0),
+		Interval:   10 * time.Second,
+		Values:     []api.Value{api.Derive(1)},
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := w.Write(context.Background(), &vl); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	if buf.Len() == 0 {
+		t.Fatal("Writer did not flush once BufferSize was exceeded")
+	}
+}
+
+// packetWriter records each Write call as a separate packet, mimicking how
+// a UDP socket delivers one datagram per Write.
+type packetWriter struct {
+	packets [][]byte
+}
+
+func (pw *packetWriter) Write(b []byte) (int, error) {
+	packet := append([]byte{}, b...)
+	pw.packets = append(pw.packets, packet)
+	return len(b), nil
+}
+
+func TestWriterResetsStateBetweenPackets(t *testing.T) {
+	var pw packetWriter
+	w := NewWriter(&pw)
+	w.BufferSize = 64
+
+	vl := api.ValueList{
+		Identifier: api.Identifier{Host: "example.com", Plugin: "cpu", Type: "cpu"},
+		Time:       time.Unix(1426588800, 0),
+		Interval:   10 * time.Second,
+		Values:     []api.Value{api.Derive(1)},
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := w.Write(context.Background(), &vl); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	if len(pw.packets) < 2 {
+		t.Fatalf("got %d packets, want at least 2 (test is not exercising multiple flushes)", len(pw.packets))
+	}
+	for i, packet := range pw.packets {
+		got, err := Parse(packet)
+		if err != nil {
+			t.Fatalf("Parse(packet %d): %v", i, err)
+		}
+		for j, got := range got {
+			if got.Identifier != vl.Identifier {
+				t.Errorf("packet %d, value list %d: Identifier = %+v, want %+v (state-diff cache leaked across packets)", i, j, got.Identifier, vl.Identifier)
+			}
+		}
+	}
+}
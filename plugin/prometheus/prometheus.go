@@ -0,0 +1,212 @@
+// Package prometheus implements an api.Writer that exposes value lists
+// received from collectd as Prometheus / OpenMetrics metrics, turning a Go
+// binary embedding a network.Server into a collectd-to-Prometheus bridge.
+package prometheus // import "collectd.org/plugin/prometheus"
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"collectd.org/api"
+	"collectd.org/network"
+)
+
+// Collector implements api.Writer and prometheus.Collector. It lazily
+// creates a Prometheus metric for every (Plugin, Type, data-source) triple
+// it sees, tagging Host, PluginInstance and TypeInstance as labels, and
+// evicts series that haven't been updated for Staleness.
+type Collector struct {
+	// Types, if set, is consulted to distinguish collectd's "counter"
+	// and "derive" data sources (exposed as monotonic Prometheus
+	// counters) from "gauge" and "absolute" ones (exposed as Prometheus
+	// gauges). If a data source isn't found, the dynamic type of the
+	// api.Value itself is used instead.
+	Types network.Types
+
+	// Staleness is how long a series is kept without being updated
+	// before Collect evicts it. If zero, it defaults to twice the
+	// Interval reported for that series.
+	Staleness time.Duration
+
+	mu     sync.Mutex
+	series map[seriesKey]*series
+}
+
+// NewCollector returns an empty Collector. Register it with a
+// prometheus.Registerer, or use its Handler, to expose its metrics.
+func NewCollector() *Collector {
+	return &Collector{series: make(map[seriesKey]*series)}
+}
+
+type seriesKey struct {
+	api.Identifier
+	ds string
+}
+
+type series struct {
+	desc     *prometheus.Desc
+	kind     prometheus.ValueType
+	labels   []string
+	value    float64
+	lastSeen time.Time
+	interval time.Duration
+}
+
+// Write implements api.Writer, updating (and lazily creating) the series
+// for every value in vl.
+func (c *Collector) Write(_ context.Context, vl *api.ValueList) error {
+	if len(vl.Values) == 0 {
+		return fmt.Errorf("prometheus: value list for %q has no values", vl.Identifier)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, v := range vl.Values {
+		ds := dsName(vl, i)
+		key := seriesKey{Identifier: vl.Identifier, ds: ds}
+
+		s, ok := c.series[key]
+		if !ok {
+			s = &series{desc: newDesc(vl.Plugin, vl.Type, ds)}
+			c.series[key] = s
+		}
+
+		s.kind = kindOf(c.Types, vl.Type, ds, v)
+		s.labels = []string{vl.Host, vl.PluginInstance, vl.TypeInstance}
+		s.value = valueOf(v)
+		s.lastSeen = vl.Time
+		s.interval = vl.Interval
+	}
+
+	return nil
+}
+
+// Describe implements prometheus.Collector. Descriptors depend on the
+// plugins collectd happens to send, so none are known ahead of time and
+// Describe sends none, which makes c an unchecked collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector, additionally evicting series
+// that haven't been updated for Staleness.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, s := range c.series {
+		staleness := c.Staleness
+		if staleness <= 0 {
+			staleness = 2 * s.interval
+		}
+		if staleness > 0 && now.Sub(s.lastSeen) > staleness {
+			delete(c.series, key)
+			continue
+		}
+
+		m, err := prometheus.NewConstMetric(s.desc, s.kind, s.value, s.labels...)
+		if err != nil {
+			continue
+		}
+		ch <- m
+	}
+}
+
+// Handler returns an http.Handler, compatible with promhttp.Handler, that
+// exposes c's metrics in the Prometheus/OpenMetrics exposition format.
+func (c *Collector) Handler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func newDesc(plugin, typ, ds string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		metricName(plugin, typ, ds),
+		fmt.Sprintf("collectd %s/%s/%s, bridged from the network plugin.", plugin, typ, ds),
+		[]string{"host", "plugin_instance", "type_instance"},
+		nil,
+	)
+}
+
+func dsName(vl *api.ValueList, i int) string {
+	if i < len(vl.DSNames) && vl.DSNames[i] != "" {
+		return vl.DSNames[i]
+	}
+	return "value"
+}
+
+// metricName derives a Prometheus metric name from plugin, type and
+// data-source name, the (Plugin, Type, data-source) triple a metric is
+// created for; the other identifier fields are labels. Type is folded in unless it is redundant with plugin (as it
+// commonly is, e.g. the "load" plugin's "load" type), so that two types
+// sharing a data-source name on the same plugin - collectd's "interface"
+// plugin reports both if_octets and if_errors with DS names rx/tx - don't
+// collapse onto the same series.
+func metricName(plugin, typ, ds string) string {
+	name := "collectd_" + plugin
+	if typ != "" && typ != plugin {
+		name += "_" + typ
+	}
+	if ds != "value" {
+		name += "_" + ds
+	}
+	return sanitize(name)
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+// kindOf determines whether ds should be exposed as a Prometheus counter or
+// gauge, preferring the definition in types, and falling back to v's own
+// dynamic type when types is nil or doesn't have a matching entry.
+func kindOf(types network.Types, typ, ds string, v api.Value) prometheus.ValueType {
+	if dataSet, ok := types[typ]; ok {
+		for _, d := range dataSet {
+			if d.Name != ds {
+				continue
+			}
+			switch network.ValueTypeValues[d.Type] {
+			case "counter", "derive":
+				return prometheus.CounterValue
+			default:
+				return prometheus.GaugeValue
+			}
+		}
+	}
+
+	switch v.Type() {
+	case api.TypeCounter, api.TypeDerive:
+		return prometheus.CounterValue
+	default:
+		return prometheus.GaugeValue
+	}
+}
+
+func valueOf(v api.Value) float64 {
+	switch value := v.(type) {
+	case api.Gauge:
+		return float64(value)
+	case api.Derive:
+		return float64(value)
+	case api.Counter:
+		return float64(value)
+	default:
+		return 0
+	}
+}
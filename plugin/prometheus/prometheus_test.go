@@ -0,0 +1,163 @@
+package prometheus
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/network"
+)
+
+func TestCollectorExposesGaugesAndCounters(t *testing.T) {
+	types, err := network.TypesDB([]byte("load\tshortterm:GAUGE:0:100\ncpu\tvalue:DERIVE:0:U\n"))
+	if err != nil {
+		t.Fatalf("TypesDB: %v", err)
+	}
+
+	c := NewCollector()
+	c.Types = types
+
+	vls := []api.ValueList{
+		{
+			Identifier: api.Identifier{Host: "example.com", Plugin: "load", Type: "load"},
+			Time:       time.Now(),
+			Interval:   10 * time.Second,
+			Values:     []api.Value{api.Gauge(1.5)},
+			DSNames:    []string{"shortterm"},
+		},
+		{
+			Identifier: api.Identifier{Host: "example.com", Plugin: "cpu", Type: "cpu", TypeInstance: "0-user"},
+			Time:       time.Now(),
+			Interval:   10 * time.Second,
+			Values:     []api.Value{api.Derive(42)},
+			DSNames:    []string{"value"},
+		},
+	}
+	for i := range vls {
+		if err := c.Write(context.Background(), &vls[i]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	srv := httptest.NewServer(c.Handler())
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+
+	for _, want := range []string{"collectd_load_shortterm", "collectd_cpu"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("response does not contain metric %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestCollectorKeepsDistinctSeriesPerHost(t *testing.T) {
+	c := NewCollector()
+
+	vls := []api.ValueList{
+		{
+			Identifier: api.Identifier{Host: "host1.example.com", Plugin: "cpu", Type: "cpu"},
+			Time:       time.Now(),
+			Interval:   10 * time.Second,
+			Values:     []api.Value{api.Derive(1)},
+		},
+		{
+			Identifier: api.Identifier{Host: "host2.example.com", Plugin: "cpu", Type: "cpu"},
+			Time:       time.Now(),
+			Interval:   10 * time.Second,
+			Values:     []api.Value{api.Derive(2)},
+		},
+	}
+	for i := range vls {
+		if err := c.Write(context.Background(), &vls[i]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	srv := httptest.NewServer(c.Handler())
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+
+	for _, want := range []string{`host="host1.example.com"`, `host="host2.example.com"`} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("response is missing series for %s; a same plugin/type series from another host clobbered it:\n%s", want, body)
+		}
+	}
+}
+
+func TestCollectorKeepsDistinctSeriesPerType(t *testing.T) {
+	// collectd's "interface" plugin reports both if_octets and if_errors
+	// types, both with DS names rx/tx; they must not collapse onto the
+	// same Prometheus series (or Collect would hand promhttp two metrics
+	// with identical name and labels, which the client library rejects).
+	c := NewCollector()
+
+	vls := []api.ValueList{
+		{
+			Identifier: api.Identifier{Host: "example.com", Plugin: "interface", Type: "if_octets"},
+			Time:       time.Now(),
+			Interval:   10 * time.Second,
+			Values:     []api.Value{api.Derive(1), api.Derive(2)},
+			DSNames:    []string{"rx", "tx"},
+		},
+		{
+			Identifier: api.Identifier{Host: "example.com", Plugin: "interface", Type: "if_errors"},
+			Time:       time.Now(),
+			Interval:   10 * time.Second,
+			Values:     []api.Value{api.Derive(3), api.Derive(4)},
+			DSNames:    []string{"rx", "tx"},
+		},
+	}
+	for i := range vls {
+		if err := c.Write(context.Background(), &vls[i]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	srv := httptest.NewServer(c.Handler())
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		t.Fatalf("Get returned status %d, want 200 (gathering likely failed on a metric collision)", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+
+	for _, want := range []string{"collectd_interface_if_octets_rx", "collectd_interface_if_errors_rx"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("response is missing metric %q; if_octets and if_errors collided on their shared DS names:\n%s", want, body)
+		}
+	}
+}